@@ -0,0 +1,323 @@
+package ratelimit
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/ratelimit"
+)
+
+// CompositeRatelimitName is the filter name seen in eskip routes.
+const CompositeRatelimitName = "compositeRatelimit"
+
+// MinimumIntervalRatelimitName is the filter name seen in eskip routes.
+const MinimumIntervalRatelimitName = "minimumIntervalRatelimit"
+
+// tier is a single rate limit check, shared by the filters composed by
+// compositeRatelimit and by the top-level *filter and minimumIntervalFilter
+// types.
+type tier interface {
+	check(ctx filters.FilterContext) (ok bool, retryAfter int, setting ratelimit.Settings)
+}
+
+type compositeSpec struct {
+	provider RatelimitProvider
+}
+
+// NewCompositeRateLimit creates a filter spec for compositeRatelimit, which
+// wraps several rate limit tiers and denies the request if any of them
+// would, returning the headers of the most restrictive failing tier. Like
+// the other *RatelimitFilter constructors in this package except
+// minimumIntervalRatelimit, it accepts the trailing "exceptions=..." and
+// "reset=..." modifier arguments.
+//
+// Example:
+//
+//    login: Path("/login")
+//    -> compositeRatelimit("client:20/1m@Authorization", "service:10000/1m", "minimum:100ms")
+//    -> "https://login.backend.net";
+func NewCompositeRateLimit(provider RatelimitProvider) filters.Spec {
+	return &compositeSpec{provider: provider}
+}
+
+func (*compositeSpec) Name() string { return CompositeRatelimitName }
+
+func (s *compositeSpec) CreateFilter(args []interface{}) (filters.Filter, error) {
+	args, mods, err := splitTrailingArgs(args)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(args) == 0 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	cf := &compositeFilter{filterModifiers: *mods}
+	for _, a := range args {
+		spec, err := getStringArg(a)
+		if err != nil {
+			return nil, err
+		}
+
+		t, err := parseTierSpec(spec)
+		if err != nil {
+			return nil, err
+		}
+
+		if f, ok := t.(*filter); ok {
+			f.provider = s.provider
+		}
+
+		cf.tiers = append(cf.tiers, t)
+	}
+
+	return cf, nil
+}
+
+// parseTierSpec parses one composite tier, e.g. "client:20/1m@Authorization",
+// "service:10000/1m", "cluster:groupA:5000/1m", "clusterClient:groupB:20/1h@Authorization"
+// or "minimum:100ms", reusing the same Settings shape as the standalone
+// filter constructors in this package. The cluster/clusterClient tiers
+// require an explicit group, exactly like clusterRatelimit/
+// clusterClientRatelimit do, so that two unrelated routes don't
+// accidentally share one global counter.
+func parseTierSpec(spec string) (tier, error) {
+	kind, rest, ok := strings.Cut(spec, ":")
+	if !ok {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	if kind == "minimum" {
+		d, err := time.ParseDuration(rest)
+		if err != nil {
+			return nil, filters.ErrInvalidFilterParameters
+		}
+		return newMinimumIntervalFilter(d, ratelimit.NewXForwardedForLookuper()), nil
+	}
+
+	typ, ok := tierTypes[kind]
+	if !ok {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	var group string
+	if typ == ratelimit.ClusterServiceRatelimit || typ == ratelimit.ClusterClientRatelimit {
+		group, rest, ok = strings.Cut(rest, ":")
+		if !ok || group == "" {
+			return nil, filters.ErrInvalidFilterParameters
+		}
+	}
+
+	rateWindow, headerName, _ := strings.Cut(rest, "@")
+	maxHitsString, windowString, ok := strings.Cut(rateWindow, "/")
+	if !ok {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	maxHits, err := strconv.Atoi(maxHitsString)
+	if err != nil {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	window, err := time.ParseDuration(windowString)
+	if err != nil {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	settings := ratelimit.Settings{
+		Type:          typ,
+		Group:         group,
+		MaxHits:       maxHits,
+		TimeWindow:    window,
+		CleanInterval: 10 * window,
+	}
+
+	switch {
+	case typ == ratelimit.ServiceRatelimit || typ == ratelimit.ClusterServiceRatelimit:
+		settings.Lookuper = ratelimit.NewSameBucketLookuper()
+	case headerName != "":
+		settings.Lookuper = getLookuper(headerName)
+	default:
+		settings.Lookuper = ratelimit.NewXForwardedForLookuper()
+	}
+
+	return &filter{settings: settings, overwrites: make(map[string]ratelimit.Settings)}, nil
+}
+
+var tierTypes = map[string]ratelimit.RatelimitType{
+	"client":        ratelimit.ClientRatelimit,
+	"service":       ratelimit.ServiceRatelimit,
+	"cluster":       ratelimit.ClusterServiceRatelimit,
+	"clusterClient": ratelimit.ClusterClientRatelimit,
+}
+
+type compositeFilter struct {
+	tiers []tier
+	filterModifiers
+}
+
+// check evaluates every tier and, if any denies the request, returns the
+// Retry-After and Settings of the tier with the longest Retry-After, i.e.
+// the most restrictive one.
+func (cf *compositeFilter) check(ctx filters.FilterContext) (bool, int, ratelimit.Settings) {
+	if cf.exempt(ctx) {
+		return true, 0, ratelimit.Settings{}
+	}
+
+	var (
+		denied     bool
+		retryAfter int
+		setting    ratelimit.Settings
+	)
+
+	for _, t := range cf.tiers {
+		ok, ra, s := t.check(ctx)
+		if ok {
+			continue
+		}
+		if !denied || ra > retryAfter {
+			denied, retryAfter, setting = true, ra, s
+		}
+	}
+
+	return !denied, retryAfter, setting
+}
+
+// Request evaluates every tier and, if any denies the request, serves the
+// 429 response of the tier with the longest Retry-After, i.e. the most
+// restrictive one.
+func (cf *compositeFilter) Request(ctx filters.FilterContext) {
+	if ok, retryAfter, setting := cf.check(ctx); !ok {
+		cf.serveLimitExceeded(ctx, setting, retryAfter)
+	}
+}
+
+func (*compositeFilter) Response(filters.FilterContext) {}
+
+type minimumIntervalSpec struct{}
+
+// NewMinimumIntervalRatelimit creates a filter spec for
+// minimumIntervalRatelimit, which enforces a lower bound on the time
+// between two allowed requests from the same client, regardless of how
+// many requests arrived in between. It does not accept the trailing
+// "exceptions=..." and "reset=..." modifier arguments: an optional second
+// argument is always taken as the name of the header that identifies the
+// client.
+//
+// Example:
+//
+//    login: Path("/login")
+//    -> minimumIntervalRatelimit("100ms")
+//    -> "https://login.backend.net";
+func NewMinimumIntervalRatelimit() filters.Spec {
+	return &minimumIntervalSpec{}
+}
+
+func (*minimumIntervalSpec) Name() string { return MinimumIntervalRatelimitName }
+
+func (*minimumIntervalSpec) CreateFilter(args []interface{}) (filters.Filter, error) {
+	if !(len(args) == 1 || len(args) == 2) {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	interval, err := getDurationArg(args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	lookuper := ratelimit.NewXForwardedForLookuper()
+	if len(args) == 2 {
+		lookuperString, err := getStringArg(args[1])
+		if err != nil {
+			return nil, err
+		}
+		lookuper = ratelimit.NewHeaderLookuper(lookuperString)
+	}
+
+	return newMinimumIntervalFilter(interval, lookuper), nil
+}
+
+// minimumIntervalSweepEvery bounds how many entries minimumIntervalFilter.last
+// accumulates between sweeps of the clients that haven't been seen again
+// since their last request, so a filter instance fed by a long tail of
+// one-off clients doesn't retain one entry per client forever.
+const minimumIntervalSweepEvery = 1024
+
+type minimumIntervalFilter struct {
+	interval time.Duration
+	lookuper ratelimit.Lookuper
+
+	mu    sync.Mutex
+	last  map[string]time.Time
+	calls int
+}
+
+func newMinimumIntervalFilter(interval time.Duration, lookuper ratelimit.Lookuper) *minimumIntervalFilter {
+	return &minimumIntervalFilter{
+		interval: interval,
+		lookuper: lookuper,
+		last:     make(map[string]time.Time),
+	}
+}
+
+// sweep deletes entries whose interval has already elapsed, since they no
+// longer affect any future decision and only take up space.
+func (f *minimumIntervalFilter) sweep(now time.Time) {
+	for key, last := range f.last {
+		if now.Sub(last) >= f.interval {
+			delete(f.last, key)
+		}
+	}
+}
+
+func (f *minimumIntervalFilter) check(ctx filters.FilterContext) (bool, int, ratelimit.Settings) {
+	key := f.lookuper.Lookup(ctx.Request())
+	if key == "" {
+		return true, 0, ratelimit.Settings{}
+	}
+
+	settings := ratelimit.Settings{
+		Type:       ratelimit.ClientRatelimit,
+		MaxHits:    1,
+		TimeWindow: f.interval,
+	}
+
+	now := time.Now()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.calls++
+	if f.calls >= minimumIntervalSweepEvery {
+		f.calls = 0
+		f.sweep(now)
+	}
+
+	if last, ok := f.last[key]; ok {
+		if elapsed := now.Sub(last); elapsed < f.interval {
+			retryAfter := int((f.interval - elapsed) / time.Second)
+			if retryAfter < 1 {
+				retryAfter = 1
+			}
+			return false, retryAfter, settings
+		}
+	}
+
+	f.last[key] = now
+	return true, 0, settings
+}
+
+func (f *minimumIntervalFilter) Request(ctx filters.FilterContext) {
+	if ok, retryAfter, setting := f.check(ctx); !ok {
+		ctx.Serve(&http.Response{
+			StatusCode: http.StatusTooManyRequests,
+			Header:     ratelimit.Headers(&setting, retryAfter),
+		})
+	}
+}
+
+func (*minimumIntervalFilter) Response(filters.FilterContext) {}
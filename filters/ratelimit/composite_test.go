@@ -0,0 +1,112 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/zalando/skipper/ratelimit"
+)
+
+func TestParseTierSpec(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		spec    string
+		wantErr bool
+	}{
+		{name: "client with header lookuper", spec: "client:20/1m@Authorization"},
+		{name: "service", spec: "service:10000/1m"},
+		{name: "minimum", spec: "minimum:100ms"},
+		{name: "cluster with group", spec: "cluster:groupA:5000/1m"},
+		{name: "clusterClient with group and header", spec: "clusterClient:groupB:20/1h@Authorization"},
+		{name: "cluster without group is rejected", spec: "cluster:5000/1m", wantErr: true},
+		{name: "clusterClient without group is rejected", spec: "clusterClient:20/1h", wantErr: true},
+		{name: "cluster with empty group is rejected", spec: "cluster::5000/1m", wantErr: true},
+		{name: "unknown kind", spec: "bogus:20/1m", wantErr: true},
+		{name: "missing separator", spec: "client20/1m", wantErr: true},
+		{name: "bad rate", spec: "client:nope/1m", wantErr: true},
+		{name: "bad window", spec: "client:20/nope", wantErr: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseTierSpec(tt.spec)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error for spec %q, got tier %#v", tt.spec, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error for spec %q: %v", tt.spec, err)
+			}
+			if got == nil {
+				t.Fatalf("expected a tier for spec %q", tt.spec)
+			}
+		})
+	}
+}
+
+func TestParseTierSpecClusterGroup(t *testing.T) {
+	got, err := parseTierSpec("cluster:groupA:5000/1m")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	f, ok := got.(*filter)
+	if !ok {
+		t.Fatalf("expected *filter, got %T", got)
+	}
+
+	if f.settings.Group != "groupA" {
+		t.Errorf("got group %q, want %q", f.settings.Group, "groupA")
+	}
+	if f.settings.MaxHits != 5000 {
+		t.Errorf("got MaxHits %d, want 5000", f.settings.MaxHits)
+	}
+	if f.settings.TimeWindow != time.Minute {
+		t.Errorf("got TimeWindow %v, want %v", f.settings.TimeWindow, time.Minute)
+	}
+	if f.settings.Type != ratelimit.ClusterServiceRatelimit {
+		t.Errorf("got Type %v, want ClusterServiceRatelimit", f.settings.Type)
+	}
+}
+
+func TestCompositeSpecCreateFilterAcceptsModifiers(t *testing.T) {
+	s := &compositeSpec{}
+
+	got, err := s.CreateFilter([]interface{}{
+		"client:20/1m", "exceptions=cidr:10.0.0.0/8", "reset=iso8601",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	cf, ok := got.(*compositeFilter)
+	if !ok {
+		t.Fatalf("expected *compositeFilter, got %T", got)
+	}
+
+	if cf.exceptions == nil {
+		t.Error("expected exceptions matcher to be set")
+	}
+	if !cf.resetISO8601 {
+		t.Error("expected resetISO8601 to be true")
+	}
+	if len(cf.tiers) != 1 {
+		t.Errorf("got %d tiers, want 1", len(cf.tiers))
+	}
+}
+
+func TestMinimumIntervalFilterSweepsStaleEntries(t *testing.T) {
+	f := newMinimumIntervalFilter(time.Millisecond, ratelimit.NewXForwardedForLookuper())
+
+	f.last["stale"] = time.Now().Add(-time.Hour)
+	f.last["fresh"] = time.Now()
+
+	f.sweep(time.Now())
+
+	if _, ok := f.last["stale"]; ok {
+		t.Error("expected the entry whose interval already elapsed to be swept")
+	}
+	if _, ok := f.last["fresh"]; !ok {
+		t.Error("expected the entry still within its interval to be kept")
+	}
+}
@@ -0,0 +1,120 @@
+package ratelimit
+
+import (
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/zalando/skipper/filters"
+)
+
+// exceptionsArgPrefix marks the optional trailing filter argument that
+// exempts matching requests from the rate limit check, e.g.
+// "exceptions=cidr:10.0.0.0/8,ua:GoogleBot*,header:X-Internal-Request=true".
+const exceptionsArgPrefix = "exceptions="
+
+// exceptionMatcher decides whether a request is exempt from rate limiting.
+// A request matches if any configured CIDR, header value, or user-agent
+// glob matches.
+type exceptionMatcher struct {
+	cidrs      []*net.IPNet
+	headers    map[string]string
+	userAgents []*regexp.Regexp
+}
+
+func (m *exceptionMatcher) match(r *http.Request) bool {
+	if m == nil {
+		return false
+	}
+
+	if len(m.cidrs) > 0 {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			host = r.RemoteAddr
+		}
+		if ip := net.ParseIP(host); ip != nil {
+			for _, n := range m.cidrs {
+				if n.Contains(ip) {
+					return true
+				}
+			}
+		}
+	}
+
+	for header, value := range m.headers {
+		if r.Header.Get(header) == value {
+			return true
+		}
+	}
+
+	if len(m.userAgents) > 0 {
+		ua := r.UserAgent()
+		for _, pattern := range m.userAgents {
+			if pattern.MatchString(ua) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// globToRegexp compiles a shell-style glob (only "*" and "?" are special)
+// into an anchored regexp. Unlike path.Match/filepath.Match, "*" matches
+// across "/", which real User-Agent strings (e.g. "GoogleBot/2.1",
+// "curl/7.68.0") contain as a matter of course.
+func globToRegexp(pattern string) (*regexp.Regexp, error) {
+	var b strings.Builder
+	b.WriteByte('^')
+	for _, r := range pattern {
+		switch r {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteByte('$')
+	return regexp.Compile(b.String())
+}
+
+// parseExceptionsArg parses the value of an "exceptions=..." filter
+// argument into an exceptionMatcher.
+func parseExceptionsArg(value string) (*exceptionMatcher, error) {
+	m := &exceptionMatcher{headers: make(map[string]string)}
+
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(entry, "cidr:"):
+			_, n, err := net.ParseCIDR(strings.TrimPrefix(entry, "cidr:"))
+			if err != nil {
+				return nil, filters.ErrInvalidFilterParameters
+			}
+			m.cidrs = append(m.cidrs, n)
+		case strings.HasPrefix(entry, "ua:"):
+			re, err := globToRegexp(strings.TrimPrefix(entry, "ua:"))
+			if err != nil {
+				return nil, filters.ErrInvalidFilterParameters
+			}
+			m.userAgents = append(m.userAgents, re)
+		case strings.HasPrefix(entry, "header:"):
+			kv := strings.SplitN(strings.TrimPrefix(entry, "header:"), "=", 2)
+			if len(kv) != 2 || kv[0] == "" {
+				return nil, filters.ErrInvalidFilterParameters
+			}
+			m.headers[http.CanonicalHeaderKey(kv[0])] = kv[1]
+		default:
+			return nil, filters.ErrInvalidFilterParameters
+		}
+	}
+
+	return m, nil
+}
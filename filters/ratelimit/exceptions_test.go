@@ -0,0 +1,116 @@
+package ratelimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseExceptionsArg(t *testing.T) {
+	m, err := parseExceptionsArg("cidr:10.0.0.0/8,ua:GoogleBot*,header:X-Internal-Request=true")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, tt := range []struct {
+		name    string
+		request *http.Request
+		want    bool
+	}{
+		{
+			name: "matching cidr",
+			request: func() *http.Request {
+				r := httptest.NewRequest("GET", "/", nil)
+				r.RemoteAddr = "10.1.2.3:1234"
+				return r
+			}(),
+			want: true,
+		},
+		{
+			name: "non-matching cidr",
+			request: func() *http.Request {
+				r := httptest.NewRequest("GET", "/", nil)
+				r.RemoteAddr = "192.168.1.1:1234"
+				return r
+			}(),
+			want: false,
+		},
+		{
+			name: "matching user agent glob",
+			request: func() *http.Request {
+				r := httptest.NewRequest("GET", "/", nil)
+				r.Header.Set("User-Agent", "GoogleBot/2.1")
+				return r
+			}(),
+			want: true,
+		},
+		{
+			name: "user agent glob is anchored, not substring",
+			request: func() *http.Request {
+				r := httptest.NewRequest("GET", "/", nil)
+				r.Header.Set("User-Agent", "Mozilla/5.0 GoogleBot/2.1 (+http://www.google.com/bot.html)")
+				return r
+			}(),
+			want: false,
+		},
+		{
+			name: "matching header",
+			request: func() *http.Request {
+				r := httptest.NewRequest("GET", "/", nil)
+				r.Header.Set("X-Internal-Request", "true")
+				return r
+			}(),
+			want: true,
+		},
+		{
+			name:    "no match",
+			request: httptest.NewRequest("GET", "/", nil),
+			want:    false,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := m.match(tt.request); got != tt.want {
+				t.Errorf("match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseExceptionsArgErrors(t *testing.T) {
+	for _, value := range []string{
+		"cidr:not-a-cidr",
+		"header:missing-value",
+		"bogus:foo",
+	} {
+		if _, err := parseExceptionsArg(value); err == nil {
+			t.Errorf("expected error for %q", value)
+		}
+	}
+}
+
+func TestNilExceptionMatcher(t *testing.T) {
+	var m *exceptionMatcher
+	if m.match(httptest.NewRequest("GET", "/", nil)) {
+		t.Error("nil matcher should never match")
+	}
+}
+
+func TestGlobToRegexpMatchesAcrossSlash(t *testing.T) {
+	re, err := globToRegexp("GoogleBot*")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, tt := range []struct {
+		ua   string
+		want bool
+	}{
+		{ua: "GoogleBot/2.1", want: true},
+		{ua: "GoogleBot/2.1 (+http://www.google.com/bot.html)", want: true},
+		{ua: "curl/7.68.0", want: false},
+	} {
+		if got := re.MatchString(tt.ua); got != tt.want {
+			t.Errorf("globToRegexp(%q).MatchString(%q) = %v, want %v", "GoogleBot*", tt.ua, got, tt.want)
+		}
+	}
+}
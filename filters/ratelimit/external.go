@@ -0,0 +1,312 @@
+package ratelimit
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	rlsv3 "github.com/envoyproxy/go-control-plane/envoy/service/ratelimit/v3"
+	rlstypesv3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/ratelimit"
+)
+
+// ExternalRatelimitName is the filter name seen in eskip routes.
+const ExternalRatelimitName = "externalRatelimit"
+
+// descriptorEntry builds one RateLimitDescriptor_Entry from the request,
+// returning ok=false if the configured source has no value for it.
+type descriptorEntry struct {
+	key    string
+	lookup func(*http.Request) (string, bool)
+}
+
+func headerDescriptorEntry(key, header string) descriptorEntry {
+	header = http.CanonicalHeaderKey(header)
+	return descriptorEntry{
+		key: key,
+		lookup: func(r *http.Request) (string, bool) {
+			v := r.Header.Get(header)
+			return v, v != ""
+		},
+	}
+}
+
+func pathDescriptorEntry(key string) descriptorEntry {
+	return descriptorEntry{
+		key: key,
+		lookup: func(r *http.Request) (string, bool) {
+			return r.URL.Path, true
+		},
+	}
+}
+
+func methodDescriptorEntry(key string) descriptorEntry {
+	return descriptorEntry{
+		key: key,
+		lookup: func(r *http.Request) (string, bool) {
+			return r.Method, true
+		},
+	}
+}
+
+func remoteIPDescriptorEntry(key string) descriptorEntry {
+	return descriptorEntry{
+		key: key,
+		lookup: func(r *http.Request) (string, bool) {
+			host, _, err := net.SplitHostPort(r.RemoteAddr)
+			if err != nil {
+				host = r.RemoteAddr
+			}
+			return host, host != ""
+		},
+	}
+}
+
+// externalRatelimitSpec creates externalRatelimit filters and pools one
+// grpc.ClientConn per distinct service address across all filter instances.
+type externalRatelimitSpec struct {
+	mu    sync.Mutex
+	conns map[string]*grpc.ClientConn
+}
+
+// NewExternalRateLimit creates a filter spec for externalRatelimit, which
+// delegates the rate limit decision to a remote, Envoy-protocol compatible
+// rate limit service (e.g. Envoy RLS or gubernator) instead of evaluating it
+// in-process. The provider argument is accepted for symmetry with the other
+// constructors in this package and is currently unused, as externalRatelimit
+// keeps no local limiter state. Like the other filters in this package
+// except minimumIntervalRatelimit, it accepts the trailing "exceptions=..."
+// and "reset=iso8601" modifier arguments handled by splitTrailingArgs.
+//
+// Example:
+//
+//    login: Path("/login")
+//    -> externalRatelimit("rls.example.org:8081", "login-domain", "remote-address", "header:X-User:user")
+//    -> "https://login.backend.net";
+func NewExternalRateLimit(provider RatelimitProvider) filters.Spec {
+	return &externalRatelimitSpec{conns: make(map[string]*grpc.ClientConn)}
+}
+
+func (*externalRatelimitSpec) Name() string { return ExternalRatelimitName }
+
+type externalRatelimitFilter struct {
+	domain   string
+	entries  []descriptorEntry
+	client   rlsv3.RateLimitServiceClient
+	timeout  time.Duration
+	failOpen bool
+	filterModifiers
+}
+
+func (s *externalRatelimitSpec) CreateFilter(args []interface{}) (filters.Filter, error) {
+	args, mods, err := splitTrailingArgs(args)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(args) < 2 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	address, err := getStringArg(args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	domain, err := getStringArg(args[1])
+	if err != nil {
+		return nil, err
+	}
+
+	f := &externalRatelimitFilter{
+		domain:          domain,
+		timeout:         100 * time.Millisecond,
+		failOpen:        true,
+		filterModifiers: *mods,
+	}
+
+	useTLS := false
+	for _, a := range args[2:] {
+		arg, err := getStringArg(a)
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case arg == "tls":
+			useTLS = true
+		case arg == "failClosed":
+			f.failOpen = false
+		case strings.HasPrefix(arg, "timeout="):
+			d, err := time.ParseDuration(strings.TrimPrefix(arg, "timeout="))
+			if err != nil {
+				return nil, filters.ErrInvalidFilterParameters
+			}
+			f.timeout = d
+		case strings.HasPrefix(arg, "header:"):
+			entry, err := parseHeaderDescriptor(arg)
+			if err != nil {
+				return nil, err
+			}
+			f.entries = append(f.entries, entry)
+		case arg == "path":
+			f.entries = append(f.entries, pathDescriptorEntry("path"))
+		case arg == "method":
+			f.entries = append(f.entries, methodDescriptorEntry("method"))
+		case arg == "remote-address":
+			f.entries = append(f.entries, remoteIPDescriptorEntry("remote_address"))
+		default:
+			return nil, filters.ErrInvalidFilterParameters
+		}
+	}
+
+	conn, err := s.connFor(address, useTLS)
+	if err != nil {
+		return nil, err
+	}
+	f.client = rlsv3.NewRateLimitServiceClient(conn)
+
+	return f, nil
+}
+
+// parseHeaderDescriptor parses "header:X-User" or "header:X-User:user" into
+// a descriptorEntry whose key defaults to the header name.
+func parseHeaderDescriptor(arg string) (descriptorEntry, error) {
+	parts := strings.SplitN(strings.TrimPrefix(arg, "header:"), ":", 2)
+	if parts[0] == "" {
+		return descriptorEntry{}, filters.ErrInvalidFilterParameters
+	}
+
+	key := parts[0]
+	if len(parts) == 2 && parts[1] != "" {
+		key = parts[1]
+	}
+	return headerDescriptorEntry(key, parts[0]), nil
+}
+
+func (s *externalRatelimitSpec) connFor(address string, useTLS bool) (*grpc.ClientConn, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if conn, ok := s.conns[address]; ok {
+		return conn, nil
+	}
+
+	var opt grpc.DialOption
+	if useTLS {
+		opt = grpc.WithTransportCredentials(credentials.NewTLS(nil))
+	} else {
+		opt = grpc.WithTransportCredentials(insecure.NewCredentials())
+	}
+
+	conn, err := grpc.Dial(address, opt)
+	if err != nil {
+		return nil, err
+	}
+
+	s.conns[address] = conn
+	return conn, nil
+}
+
+// check calls out to the external rate limit service and reports
+// ok=false with the denying tier's settings on OVER_LIMIT. On any
+// transport or service error it fails open unless failClosed was set, by
+// reporting ok=f.failOpen.
+func (f *externalRatelimitFilter) check(ctx filters.FilterContext) (bool, int, ratelimit.Settings) {
+	if f.exempt(ctx) {
+		return true, 0, ratelimit.Settings{}
+	}
+
+	req := ctx.Request()
+
+	descriptor := &rlsv3.RateLimitDescriptor{}
+	for _, e := range f.entries {
+		v, ok := e.lookup(req)
+		if !ok {
+			continue
+		}
+		descriptor.Entries = append(descriptor.Entries, &rlsv3.RateLimitDescriptor_Entry{
+			Key:   e.key,
+			Value: v,
+		})
+	}
+
+	rctx, cancel := context.WithTimeout(req.Context(), f.timeout)
+	defer cancel()
+
+	resp, err := f.client.ShouldRateLimit(rctx, &rlsv3.RateLimitRequest{
+		Domain:      f.domain,
+		Descriptors: []*rlsv3.RateLimitDescriptor{descriptor},
+		HitsAddend:  1,
+	})
+	if err != nil {
+		log.Errorf("externalRatelimit: ShouldRateLimit call failed: %v", err)
+		return f.failOpen, 0, ratelimit.Settings{}
+	}
+
+	if resp.OverallCode != rlsv3.RateLimitResponse_OVER_LIMIT {
+		return true, 0, ratelimit.Settings{}
+	}
+
+	settings, retryAfter := settingsFromResponse(resp)
+	return false, retryAfter, settings
+}
+
+// Request serves a 429 response with the standard ratelimit headers when
+// check denies the request.
+func (f *externalRatelimitFilter) Request(ctx filters.FilterContext) {
+	if ok, retryAfter, setting := f.check(ctx); !ok {
+		f.serveLimitExceeded(ctx, setting, retryAfter)
+	}
+}
+
+func (f *externalRatelimitFilter) Response(filters.FilterContext) {}
+
+// settingsFromResponse derives ratelimit.Settings and a Retry-After value
+// (in seconds) from the first failing descriptor status, so the well-known
+// X-RateLimit-* and Retry-After headers stay consistent with the in-process
+// limiters.
+func settingsFromResponse(resp *rlsv3.RateLimitResponse) (ratelimit.Settings, int) {
+	var maxHits int
+	var window time.Duration = time.Second
+
+	for _, s := range resp.Statuses {
+		if s.Code != rlsv3.RateLimitResponse_OVER_LIMIT || s.CurrentLimit == nil {
+			continue
+		}
+		maxHits = int(s.CurrentLimit.RequestsPerUnit)
+		window = unitToDuration(s.CurrentLimit.Unit)
+		break
+	}
+
+	settings := ratelimit.Settings{
+		Type:       ratelimit.ClusterServiceRatelimit,
+		MaxHits:    maxHits,
+		TimeWindow: window,
+	}
+	return settings, int(window / time.Second)
+}
+
+func unitToDuration(u rlstypesv3.RateLimitUnit) time.Duration {
+	switch u {
+	case rlstypesv3.RateLimitUnit_SECOND:
+		return time.Second
+	case rlstypesv3.RateLimitUnit_MINUTE:
+		return time.Minute
+	case rlstypesv3.RateLimitUnit_HOUR:
+		return time.Hour
+	case rlstypesv3.RateLimitUnit_DAY:
+		return 24 * time.Hour
+	default:
+		return time.Second
+	}
+}
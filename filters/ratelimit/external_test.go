@@ -0,0 +1,27 @@
+package ratelimit
+
+import "testing"
+
+func TestExternalRatelimitCreateFilterAcceptsModifiers(t *testing.T) {
+	spec := NewExternalRateLimit(nil)
+
+	f, err := spec.CreateFilter([]interface{}{
+		"rls.example.org:8081", "login-domain", "remote-address",
+		"exceptions=cidr:10.0.0.0/8", "reset=iso8601",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	erf, ok := f.(*externalRatelimitFilter)
+	if !ok {
+		t.Fatalf("expected *externalRatelimitFilter, got %T", f)
+	}
+
+	if erf.exceptions == nil {
+		t.Error("expected exceptions matcher to be set")
+	}
+	if !erf.resetISO8601 {
+		t.Error("expected resetISO8601 to be true")
+	}
+}
@@ -0,0 +1,196 @@
+package ratelimit
+
+import (
+	"encoding/json"
+	"time"
+
+	"go.etcd.io/bbolt"
+
+	"github.com/zalando/skipper/net/redis"
+	"github.com/zalando/skipper/ratelimit"
+)
+
+// OverwriteStore persists the per-client rate limit overwrites that
+// filter.Response learns from upstream responses, so they are not lost on
+// restart and, depending on the implementation, are visible to peer
+// skipper instances. Get returns ok=false once an overwrite has expired or
+// was never set.
+type OverwriteStore interface {
+	Get(key string) (settings ratelimit.Settings, ok bool)
+	Set(key string, settings ratelimit.Settings, ttl time.Duration)
+}
+
+const overwriteStoreBucket = "ratelimit-overwrites"
+
+// defaultBoltSweepInterval is how often BoltOverwriteStore scans the whole
+// bucket for expired entries in the background, bounding the disk growth
+// of clients that trigger exactly one overwrite and are never seen again.
+const defaultBoltSweepInterval = 10 * time.Minute
+
+// BoltOverwriteStore is an OverwriteStore backed by a local bbolt database,
+// giving overwrites durability across restarts of a single skipper
+// instance without requiring any external dependency. Expired entries are
+// deleted lazily on read and swept periodically in the background, so the
+// database doesn't grow unbounded over the lifetime of a long-running
+// cluster.
+type BoltOverwriteStore struct {
+	db   *bbolt.DB
+	done chan struct{}
+}
+
+// NewBoltOverwriteStore opens (creating if necessary) a bbolt database at
+// path to use as an OverwriteStore.
+func NewBoltOverwriteStore(path string) (*BoltOverwriteStore, error) {
+	db, err := bbolt.Open(path, 0o600, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bbolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists([]byte(overwriteStoreBucket))
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	s := &BoltOverwriteStore{db: db, done: make(chan struct{})}
+	go s.sweepLoop(defaultBoltSweepInterval)
+	return s, nil
+}
+
+type boltOverwriteEntry struct {
+	Settings  ratelimit.Settings
+	ExpiresAt time.Time
+}
+
+func (s *BoltOverwriteStore) Get(key string) (ratelimit.Settings, bool) {
+	var (
+		entry   boltOverwriteEntry
+		found   bool
+		expired bool
+	)
+
+	s.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(overwriteStoreBucket))
+		v := b.Get([]byte(key))
+		if v == nil {
+			return nil
+		}
+		if json.Unmarshal(v, &entry) != nil {
+			return nil
+		}
+		found = true
+		if time.Now().After(entry.ExpiresAt) {
+			expired = true
+			return b.Delete([]byte(key))
+		}
+		return nil
+	})
+
+	if !found || expired {
+		return ratelimit.Settings{}, false
+	}
+
+	return entry.Settings, true
+}
+
+func (s *BoltOverwriteStore) Set(key string, settings ratelimit.Settings, ttl time.Duration) {
+	entry := boltOverwriteEntry{Settings: settings, ExpiresAt: time.Now().Add(ttl)}
+
+	v, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+
+	s.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket([]byte(overwriteStoreBucket)).Put([]byte(key), v)
+	})
+}
+
+// sweepLoop periodically deletes expired entries so clients that only ever
+// trigger a single overwrite don't leave a permanent row behind.
+func (s *BoltOverwriteStore) sweepLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.sweep()
+		case <-s.done:
+			return
+		}
+	}
+}
+
+func (s *BoltOverwriteStore) sweep() {
+	now := time.Now()
+
+	s.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(overwriteStoreBucket))
+		c := b.Cursor()
+
+		var expired [][]byte
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			var entry boltOverwriteEntry
+			if json.Unmarshal(v, &entry) != nil {
+				continue
+			}
+			if now.After(entry.ExpiresAt) {
+				expired = append(expired, append([]byte(nil), k...))
+			}
+		}
+
+		for _, k := range expired {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Close stops the background sweeper and closes the underlying bbolt
+// database.
+func (s *BoltOverwriteStore) Close() error {
+	close(s.done)
+	return s.db.Close()
+}
+
+// RedisOverwriteStore is an OverwriteStore backed by the same Redis ring
+// client used by skipper's swarm and cluster rate limiters, so overwrites
+// learned on one instance become visible to every peer sharing the ring.
+type RedisOverwriteStore struct {
+	ring *redis.Ring
+}
+
+// NewRedisOverwriteStore creates a RedisOverwriteStore using an already
+// configured redis.Ring, as constructed for the cluster ratelimit swarm.
+func NewRedisOverwriteStore(ring *redis.Ring) *RedisOverwriteStore {
+	return &RedisOverwriteStore{ring: ring}
+}
+
+func (s *RedisOverwriteStore) Get(key string) (ratelimit.Settings, bool) {
+	v, err := s.ring.Get(overwriteStoreBucket + ":" + key)
+	if err != nil || v == "" {
+		return ratelimit.Settings{}, false
+	}
+
+	var settings ratelimit.Settings
+	if err := json.Unmarshal([]byte(v), &settings); err != nil {
+		return ratelimit.Settings{}, false
+	}
+
+	return settings, true
+}
+
+func (s *RedisOverwriteStore) Set(key string, settings ratelimit.Settings, ttl time.Duration) {
+	v, err := json.Marshal(settings)
+	if err != nil {
+		return
+	}
+
+	s.ring.Set(overwriteStoreBucket+":"+key, string(v), ttl)
+}
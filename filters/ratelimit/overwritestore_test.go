@@ -0,0 +1,81 @@
+package ratelimit
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.etcd.io/bbolt"
+
+	"github.com/zalando/skipper/ratelimit"
+)
+
+func TestBoltOverwriteStoreGetSet(t *testing.T) {
+	store, err := NewBoltOverwriteStore(filepath.Join(t.TempDir(), "overwrites.db"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer store.Close()
+
+	settings := ratelimit.Settings{Type: ratelimit.ClusterClientRatelimit, MaxHits: 42, TimeWindow: time.Minute}
+	store.Set("client-a", settings, time.Minute)
+
+	got, ok := store.Get("client-a")
+	if !ok {
+		t.Fatal("expected overwrite to be found")
+	}
+	if got.MaxHits != settings.MaxHits {
+		t.Errorf("got MaxHits %d, want %d", got.MaxHits, settings.MaxHits)
+	}
+
+	if _, ok := store.Get("unknown-client"); ok {
+		t.Error("expected no overwrite for a key that was never set")
+	}
+}
+
+func TestBoltOverwriteStoreExpiresLazilyOnRead(t *testing.T) {
+	store, err := NewBoltOverwriteStore(filepath.Join(t.TempDir(), "overwrites.db"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer store.Close()
+
+	store.Set("client-a", ratelimit.Settings{MaxHits: 1}, time.Nanosecond)
+	time.Sleep(time.Millisecond)
+
+	if _, ok := store.Get("client-a"); ok {
+		t.Fatal("expected the overwrite to have expired")
+	}
+
+	// Get must have deleted the expired entry as a side effect, not
+	// merely hidden it, so the bucket doesn't grow unbounded.
+	var stillStored bool
+	store.db.View(func(tx *bbolt.Tx) error {
+		stillStored = tx.Bucket([]byte(overwriteStoreBucket)).Get([]byte("client-a")) != nil
+		return nil
+	})
+	if stillStored {
+		t.Fatal("expected the expired entry to have been deleted, not just hidden")
+	}
+}
+
+func TestBoltOverwriteStoreSweepRemovesExpiredEntries(t *testing.T) {
+	store, err := NewBoltOverwriteStore(filepath.Join(t.TempDir(), "overwrites.db"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer store.Close()
+
+	store.Set("expired", ratelimit.Settings{MaxHits: 1}, time.Nanosecond)
+	store.Set("fresh", ratelimit.Settings{MaxHits: 1}, time.Hour)
+	time.Sleep(time.Millisecond)
+
+	store.sweep()
+
+	if _, ok := store.Get("expired"); ok {
+		t.Error("expected sweep to have removed the expired entry")
+	}
+	if _, ok := store.Get("fresh"); !ok {
+		t.Error("expected sweep to leave the still-valid entry in place")
+	}
+}
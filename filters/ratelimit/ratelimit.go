@@ -22,6 +22,7 @@ type spec struct {
 	typ        ratelimit.RatelimitType
 	provider   RatelimitProvider
 	filterName string
+	store      OverwriteStore
 }
 
 type filter struct {
@@ -29,6 +30,8 @@ type filter struct {
 	settings   ratelimit.Settings
 	provider   RatelimitProvider
 	overwrites map[string]ratelimit.Settings
+	store      OverwriteStore
+	filterModifiers
 }
 
 // RatelimitProvider returns a limit instance for provided Settings
@@ -146,6 +149,15 @@ func NewClusterClientRateLimit(provider RatelimitProvider) filters.Spec {
 	return &spec{typ: ratelimit.ClusterClientRatelimit, provider: provider, filterName: ratelimit.ClusterClientRatelimitName}
 }
 
+// NewClusterClientRateLimitWithOverwrites creates the same filter as
+// NewClusterClientRateLimit, except that the per-client overwrites learned
+// in filter.Response (see OverwriteStore) are kept in store instead of an
+// in-process map, so they survive restarts and, depending on the store
+// implementation, are shared with peer skipper instances.
+func NewClusterClientRateLimitWithOverwrites(provider RatelimitProvider, store OverwriteStore) filters.Spec {
+	return &spec{typ: ratelimit.ClusterClientRatelimit, provider: provider, filterName: ratelimit.ClusterClientRatelimitName, store: store}
+}
+
 // NewDisableRatelimit disables rate limiting
 //
 // Example:
@@ -332,9 +344,16 @@ func disableFilter([]interface{}) (*filter, error) {
 }
 
 func (s *spec) CreateFilter(args []interface{}) (filters.Filter, error) {
+	args, mods, err := splitTrailingArgs(args)
+	if err != nil {
+		return nil, err
+	}
+
 	f, err := s.createFilter(args)
 	if f != nil {
 		f.provider = s.provider
+		f.filterModifiers = *mods
+		f.store = s.store
 	}
 	return f, err
 }
@@ -386,41 +405,54 @@ func getDurationArg(a interface{}) (time.Duration, error) {
 	return time.Duration(i) * time.Second, err
 }
 
-// Request checks ratelimit using filter settings and serves `429 Too Many Requests` response if limit is reached
-func (f *filter) Request(ctx filters.FilterContext) {
+// check evaluates f's settings against ctx, so it can be reused both by
+// Request and by tiers composed by compositeRatelimit. It returns ok=true
+// when the request may proceed. Like Request, it counts the request
+// against the rate limiter and, for an exempt request, increments the
+// exceptions metric.
+func (f *filter) check(ctx filters.FilterContext) (ok bool, retryAfter int, setting ratelimit.Settings) {
+	setting = f.settings
+
+	if f.exempt(ctx) {
+		return true, 0, setting
+	}
+
 	rateLimiter := f.provider.get(f.settings)
 	if rateLimiter == nil {
 		log.Errorf("RateLimiter is nil for settings: %s", f.settings)
-		return
+		return true, 0, setting
 	}
 
 	if f.settings.Lookuper == nil {
 		log.Errorf("Lookuper is nil for settings: %s", f.settings)
-		return
+		return true, 0, setting
 	}
 
 	s := f.settings.Lookuper.Lookup(ctx.Request())
 	if s == "" {
 		log.Debugf("Lookuper found no data in request for settings: %s and request: %v", f.settings, ctx.Request())
-		return
+		return true, 0, setting
 	}
 
-	setting := f.settings
 	reqCtx := ctx.Request().Context()
 
-	f.mu.Lock()
-	set, ok := f.overwrites[s]
-	f.mu.Unlock()
-	if ok {
+	set, ok2 := f.getOverwrite(s)
+	if ok2 {
 		reqCtx = context.WithValue(reqCtx, ratelimit.RateHeaderOverwrite, set)
 		setting = set
 	}
 
 	if !rateLimiter.AllowContext(reqCtx, s) {
-		ctx.Serve(&http.Response{
-			StatusCode: http.StatusTooManyRequests,
-			Header:     ratelimit.Headers(&setting, rateLimiter.RetryAfter(s)),
-		})
+		return false, rateLimiter.RetryAfter(s), setting
+	}
+
+	return true, 0, setting
+}
+
+// Request checks ratelimit using filter settings and serves `429 Too Many Requests` response if limit is reached
+func (f *filter) Request(ctx filters.FilterContext) {
+	if ok, retryAfter, setting := f.check(ctx); !ok {
+		f.serveLimitExceeded(ctx, setting, retryAfter)
 	}
 }
 
@@ -440,15 +472,39 @@ func (f *filter) Response(ctx filters.FilterContext) {
 	}
 
 	identifyClient := f.settings.Lookuper.Lookup(ctx.Request())
-	f.mu.Lock()
-	f.overwrites[identifyClient] = ratelimit.Settings{
+	f.setOverwrite(identifyClient, ratelimit.Settings{
 		Type:          f.settings.Type,
 		Group:         f.settings.Group, // TODO(sszuecs): we could change group to merge clients
 		MaxHits:       n,
 		TimeWindow:    d,
 		CleanInterval: 10 * d,
-	}
-	f.mu.Unlock()
+	})
 
 	log.Infof("Added overwrite for %s with %d/%v", identifyClient, n, d)
 }
+
+// getOverwrite reads a previously learned per-client overwrite, preferring
+// the configured OverwriteStore over the in-process map so that overwrites
+// survive restarts and propagate to peers when a shared store is used.
+func (f *filter) getOverwrite(key string) (ratelimit.Settings, bool) {
+	if f.store != nil {
+		return f.store.Get(key)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	set, ok := f.overwrites[key]
+	return set, ok
+}
+
+// setOverwrite persists a per-client overwrite, see getOverwrite.
+func (f *filter) setOverwrite(key string, settings ratelimit.Settings) {
+	if f.store != nil {
+		f.store.Set(key, settings, settings.CleanInterval)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.overwrites[key] = settings
+}
@@ -0,0 +1,131 @@
+package ratelimit
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/ratelimit"
+)
+
+// resetArgPrefix marks the optional trailing filter argument that selects
+// the format of the X-RateLimit-Reset header, e.g. "reset=iso8601". The
+// only other supported value is "reset=seconds", which is also the
+// default and matches the existing behavior of ratelimit.Headers.
+const resetArgPrefix = "reset="
+
+// filterModifiers bundles the optional, order-independent trailing
+// arguments shared by every *RatelimitFilter constructor except the one of
+// minimumIntervalRatelimit.
+type filterModifiers struct {
+	exceptions   *exceptionMatcher
+	resetISO8601 bool
+}
+
+// splitTrailingArgs strips recognized "key=value" modifier arguments off
+// the end of args, in any order, and returns the remaining positional args
+// together with the parsed modifiers.
+func splitTrailingArgs(args []interface{}) ([]interface{}, *filterModifiers, error) {
+	mods := &filterModifiers{}
+
+	for len(args) > 0 {
+		last, ok := args[len(args)-1].(string)
+		if !ok {
+			break
+		}
+
+		switch {
+		case strings.HasPrefix(last, exceptionsArgPrefix):
+			m, err := parseExceptionsArg(strings.TrimPrefix(last, exceptionsArgPrefix))
+			if err != nil {
+				return nil, nil, err
+			}
+			mods.exceptions = m
+
+		case strings.HasPrefix(last, resetArgPrefix):
+			switch v := strings.TrimPrefix(last, resetArgPrefix); v {
+			case "iso8601":
+				mods.resetISO8601 = true
+			case "seconds":
+				mods.resetISO8601 = false
+			default:
+				return nil, nil, filters.ErrInvalidFilterParameters
+			}
+
+		default:
+			return args, mods, nil
+		}
+
+		args = args[:len(args)-1]
+	}
+
+	return args, mods, nil
+}
+
+// acceptsJSON reports whether the request's Accept header lists
+// application/json among its acceptable media types. Each comma-separated
+// entry is parsed as a media type rather than substring-matched, so
+// "application/jsonp" or a charset/q parameter doesn't cause a false
+// positive.
+func acceptsJSON(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		if mediaType == "application/json" {
+			return true
+		}
+	}
+	return false
+}
+
+// exempt reports whether ctx's request matches m's exceptions and, if so,
+// counts the exempt hit. Embed filterModifiers to pick this up on any
+// *RatelimitFilter implementation.
+func (m *filterModifiers) exempt(ctx filters.FilterContext) bool {
+	if !m.exceptions.match(ctx.Request()) {
+		return false
+	}
+
+	ctx.Metrics().IncCounter("ratelimit.exceptions." + ctx.Route().Id)
+	return true
+}
+
+// serveLimitExceeded builds and serves the 429 response for setting,
+// honoring the resetISO8601 and JSON-body preferences configured on m.
+// Embed filterModifiers to pick this up on any *RatelimitFilter
+// implementation.
+func (m *filterModifiers) serveLimitExceeded(ctx filters.FilterContext, setting ratelimit.Settings, retryAfter int) {
+	header := ratelimit.Headers(&setting, retryAfter)
+
+	resetValue := header.Get("X-RateLimit-Reset")
+	if m.resetISO8601 {
+		resetValue = time.Now().Add(time.Duration(retryAfter) * time.Second).UTC().Format(time.RFC3339)
+		header.Set("X-RateLimit-Reset", resetValue)
+	}
+
+	if !acceptsJSON(ctx.Request()) {
+		ctx.Serve(&http.Response{
+			StatusCode: http.StatusTooManyRequests,
+			Header:     header,
+		})
+		return
+	}
+
+	body := fmt.Sprintf(
+		`{"error":"rate_limited","limit":%d,"remaining":0,"reset":%q}`,
+		setting.MaxHits, resetValue,
+	)
+	header.Set("Content-Type", "application/json")
+
+	ctx.Serve(&http.Response{
+		StatusCode: http.StatusTooManyRequests,
+		Header:     header,
+		Body:       io.NopCloser(strings.NewReader(body)),
+	})
+}
@@ -0,0 +1,87 @@
+package ratelimit
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSplitTrailingArgs(t *testing.T) {
+	rest, mods, err := splitTrailingArgs([]interface{}{
+		20, "1m", "Authorization", "exceptions=cidr:10.0.0.0/8", "reset=iso8601",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(rest) != 3 || rest[0] != 20 || rest[1] != "1m" || rest[2] != "Authorization" {
+		t.Errorf("got remaining args %#v, want [20 1m Authorization]", rest)
+	}
+	if mods.exceptions == nil {
+		t.Error("expected exceptions matcher to be set")
+	}
+	if !mods.resetISO8601 {
+		t.Error("expected resetISO8601 to be true")
+	}
+}
+
+func TestSplitTrailingArgsOrderIndependent(t *testing.T) {
+	rest, mods, err := splitTrailingArgs([]interface{}{
+		20, "1m", "reset=iso8601", "exceptions=cidr:10.0.0.0/8",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(rest) != 2 {
+		t.Errorf("got remaining args %#v, want 2 left", rest)
+	}
+	if mods.exceptions == nil || !mods.resetISO8601 {
+		t.Errorf("expected both modifiers to be parsed regardless of order, got %#v", mods)
+	}
+}
+
+func TestSplitTrailingArgsNoModifiers(t *testing.T) {
+	rest, mods, err := splitTrailingArgs([]interface{}{20, "1m", "Authorization"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(rest) != 3 {
+		t.Errorf("got remaining args %#v, want all 3 untouched", rest)
+	}
+	if mods.exceptions != nil || mods.resetISO8601 {
+		t.Errorf("expected zero-value modifiers, got %#v", mods)
+	}
+}
+
+func TestSplitTrailingArgsInvalidReset(t *testing.T) {
+	if _, _, err := splitTrailingArgs([]interface{}{20, "1m", "reset=never"}); err == nil {
+		t.Error("expected error for invalid reset= value")
+	}
+}
+
+func TestSplitTrailingArgsInvalidExceptions(t *testing.T) {
+	if _, _, err := splitTrailingArgs([]interface{}{20, "1m", "exceptions=cidr:nope"}); err == nil {
+		t.Error("expected error for invalid exceptions= value")
+	}
+}
+
+func TestAcceptsJSON(t *testing.T) {
+	for _, tt := range []struct {
+		accept string
+		want   bool
+	}{
+		{accept: "application/json", want: true},
+		{accept: "text/html,application/json;q=0.9", want: true},
+		{accept: "text/html", want: false},
+		{accept: "", want: false},
+		{accept: "application/jsonp", want: false},
+		{accept: "application/json-patch+json", want: false},
+		{accept: "application/json; charset=utf-8", want: true},
+	} {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("Accept", tt.accept)
+		if got := acceptsJSON(r); got != tt.want {
+			t.Errorf("acceptsJSON(Accept: %q) = %v, want %v", tt.accept, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package ratelimit
+
+import (
+	"container/list"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/ratelimit"
+)
+
+// TokenBucketRatelimitName is the filter name seen in eskip routes.
+const TokenBucketRatelimitName = "tokenBucketRatelimit"
+
+// maxTokenBucketLimiters bounds the per-key limiter cache, evicting the
+// least recently used key once the limit is reached, similar to the limiter
+// cache used by the Kubernetes quota evaluator.
+const maxTokenBucketLimiters = 1 << 16
+
+type tokenBucketSpec struct{}
+
+// NewTokenBucketRatelimit creates a filter spec for tokenBucketRatelimit,
+// a smoother alternative to the window-counter based clientRatelimit that
+// refills continuously instead of resetting at fixed window boundaries.
+//
+// Example:
+//
+//    login: Path("/login")
+//    -> tokenBucketRatelimit("10/s", 20, "Authorization")
+//    -> "https://login.backend.net";
+//
+// The first argument is the refill rate, either a plain number of tokens
+// per second or a "<n>/s" string. The second argument is the bucket
+// capacity (burst size). The optional third argument selects the lookuper
+// used to derive the per-client key and defaults to XForwardedForLookuper.
+// Like the other filters in this package except minimumIntervalRatelimit,
+// it also accepts the trailing "exceptions=..." and "reset=iso8601"
+// modifier arguments handled by splitTrailingArgs.
+func NewTokenBucketRatelimit() filters.Spec {
+	return &tokenBucketSpec{}
+}
+
+func (*tokenBucketSpec) Name() string { return TokenBucketRatelimitName }
+
+func (*tokenBucketSpec) CreateFilter(args []interface{}) (filters.Filter, error) {
+	args, mods, err := splitTrailingArgs(args)
+	if err != nil {
+		return nil, err
+	}
+
+	if !(len(args) == 2 || len(args) == 3) {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	r, err := getRateArg(args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	burst, err := getIntArg(args[1])
+	if err != nil {
+		return nil, err
+	}
+
+	var lookuper ratelimit.Lookuper
+	if len(args) == 3 {
+		lookuperString, err := getStringArg(args[2])
+		if err != nil {
+			return nil, err
+		}
+		lookuper = getLookuper(lookuperString)
+	} else {
+		lookuper = ratelimit.NewXForwardedForLookuper()
+	}
+
+	return &tokenBucketFilter{
+		rate:            r,
+		burst:           burst,
+		lookuper:        lookuper,
+		limiters:        make(map[string]*rate.Limiter),
+		order:           list.New(),
+		elems:           make(map[string]*list.Element),
+		filterModifiers: *mods,
+	}, nil
+}
+
+// getRateArg parses either a plain number (tokens per second) or a
+// "<n>/s"-style string, mirroring the loose argument parsing of
+// getDurationArg elsewhere in this package.
+func getRateArg(a interface{}) (rate.Limit, error) {
+	if s, ok := a.(string); ok {
+		s = strings.TrimSuffix(s, "/s")
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return 0, filters.ErrInvalidFilterParameters
+		}
+		return rate.Limit(f), nil
+	}
+
+	if f, ok := a.(float64); ok {
+		return rate.Limit(f), nil
+	}
+
+	return 0, filters.ErrInvalidFilterParameters
+}
+
+type tokenBucketFilter struct {
+	rate     rate.Limit
+	burst    int
+	lookuper ratelimit.Lookuper
+	filterModifiers
+
+	mu       sync.Mutex
+	limiters map[string]*rate.Limiter
+	order    *list.List               // front = most recently used key
+	elems    map[string]*list.Element // key -> its node in order, for O(1) promotion
+}
+
+// limiterFor returns the token bucket for key, creating one if needed and
+// marking it as most recently used. Once the cache reaches
+// maxTokenBucketLimiters, the least recently used key is evicted first, so
+// clients that keep sending requests aren't punished for sharing the cache
+// with a larger number of clients seen only once.
+func (f *tokenBucketFilter) limiterFor(key string) *rate.Limiter {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if l, ok := f.limiters[key]; ok {
+		f.order.MoveToFront(f.elems[key])
+		return l
+	}
+
+	if len(f.limiters) >= maxTokenBucketLimiters {
+		oldest := f.order.Back()
+		oldestKey := oldest.Value.(string)
+		f.order.Remove(oldest)
+		delete(f.limiters, oldestKey)
+		delete(f.elems, oldestKey)
+	}
+
+	l := rate.NewLimiter(f.rate, f.burst)
+	f.limiters[key] = l
+	f.elems[key] = f.order.PushFront(key)
+	return l
+}
+
+// check allows the request if the per-key token bucket has capacity,
+// otherwise denies it with a Retry-After computed from the bucket's
+// reservation delay. This also makes *tokenBucketFilter usable as a
+// compositeRatelimit tier.
+func (f *tokenBucketFilter) check(ctx filters.FilterContext) (bool, int, ratelimit.Settings) {
+	if f.exempt(ctx) {
+		return true, 0, ratelimit.Settings{}
+	}
+
+	key := f.lookuper.Lookup(ctx.Request())
+	if key == "" {
+		return true, 0, ratelimit.Settings{}
+	}
+
+	limiter := f.limiterFor(key)
+	reservation := limiter.Reserve()
+	delay := reservation.Delay()
+	if delay == 0 {
+		return true, 0, ratelimit.Settings{}
+	}
+	reservation.Cancel()
+
+	retryAfter := int(delay / time.Second)
+	if retryAfter < 1 {
+		retryAfter = 1
+	}
+
+	settings := ratelimit.Settings{
+		Type:       ratelimit.ClientRatelimit,
+		MaxHits:    f.burst,
+		TimeWindow: time.Second,
+	}
+	return false, retryAfter, settings
+}
+
+// Request serves a 429 response with the standard ratelimit headers when
+// check denies the request.
+func (f *tokenBucketFilter) Request(ctx filters.FilterContext) {
+	if ok, retryAfter, setting := f.check(ctx); !ok {
+		f.serveLimitExceeded(ctx, setting, retryAfter)
+	}
+}
+
+func (*tokenBucketFilter) Response(filters.FilterContext) {}
@@ -0,0 +1,95 @@
+package ratelimit
+
+import (
+	"container/list"
+	"strconv"
+	"testing"
+
+	"golang.org/x/time/rate"
+)
+
+func TestGetRateArg(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		arg     interface{}
+		want    rate.Limit
+		wantErr bool
+	}{
+		{name: "plain number", arg: float64(100), want: 100},
+		{name: "n/s string", arg: "100/s", want: 100},
+		{name: "fractional n/s string", arg: "0.5/s", want: 0.5},
+		{name: "bad string", arg: "fast", wantErr: true},
+		{name: "wrong type", arg: true, wantErr: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := getRateArg(tt.arg)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error for %v, got %v", tt.arg, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTokenBucketCreateFilterAcceptsModifiers(t *testing.T) {
+	spec := NewTokenBucketRatelimit()
+
+	f, err := spec.CreateFilter([]interface{}{"10/s", float64(20), "Authorization", "exceptions=cidr:10.0.0.0/8", "reset=iso8601"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tbf, ok := f.(*tokenBucketFilter)
+	if !ok {
+		t.Fatalf("expected *tokenBucketFilter, got %T", f)
+	}
+
+	if tbf.exceptions == nil {
+		t.Error("expected exceptions matcher to be set")
+	}
+	if !tbf.resetISO8601 {
+		t.Error("expected resetISO8601 to be true")
+	}
+}
+
+func TestTokenBucketLimiterForEvictsLeastRecentlyUsed(t *testing.T) {
+	f := &tokenBucketFilter{
+		rate:     1,
+		burst:    1,
+		limiters: make(map[string]*rate.Limiter),
+		order:    list.New(),
+		elems:    make(map[string]*list.Element),
+	}
+
+	a := f.limiterFor("a")
+	f.limiterFor("b")
+
+	// Touching "a" again makes "b" the least recently used, not "a", even
+	// though "a" was created first.
+	if f.limiterFor("a") != a {
+		t.Fatal("expected the same limiter instance for a repeat key")
+	}
+
+	for i := 0; i < maxTokenBucketLimiters-2; i++ {
+		f.limiterFor("filler-" + strconv.Itoa(i))
+	}
+
+	// The cache is now exactly full; one more new key must evict the
+	// least recently used entry, which is "b", not "a".
+	f.limiterFor("one-too-many")
+
+	if _, ok := f.limiters["a"]; !ok {
+		t.Error("expected recently-touched key \"a\" to survive eviction")
+	}
+	if _, ok := f.limiters["b"]; ok {
+		t.Error("expected least recently used key \"b\" to have been evicted")
+	}
+}